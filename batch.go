@@ -0,0 +1,146 @@
+package dualconn
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"sync"
+
+	"github.com/smallnest/gopacket"
+	"github.com/smallnest/gopacket/layers"
+	"golang.org/x/net/ipv4"
+)
+
+// Payload describes one outbound packet for WriteBatchToIP: the
+// destination IP/port and the UDP payload to send.
+type Payload struct {
+	DstIP   string
+	DstPort uint16
+	Data    []byte
+}
+
+// serializeBufPool holds reusable gopacket.SerializeBuffers for the
+// UDPCodec fast path of WriteBatchToIP, so encoding a batch doesn't
+// allocate a fresh header buffer per packet.
+var serializeBufPool = sync.Pool{
+	New: func() any { return gopacket.NewSerializeBuffer() },
+}
+
+// WriteBatchToIP encodes each payload into an IP packet and sends the
+// whole batch in one call. On Linux this delegates to
+// ipv4.RawConn.WriteBatch, which is backed by sendmmsg; on other
+// platforms it falls back to one WriteToIP call per payload. When the
+// connection uses UDPCodec (the default), encoding draws its header
+// buffers from a pool instead of allocating one per packet; other
+// codecs go through the ordinary per-call L4Codec.Encode path.
+func (c *DualConn) WriteBatchToIP(localIP string, localPort uint16, payloads []Payload) (int, error) {
+	if localIP == "" {
+		localIP = c.localIP
+	}
+
+	src, err := netip.ParseAddr(localIP)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse local IP: %w", err)
+	}
+
+	if _, ok := c.codec.(UDPCodec); ok {
+		return c.writeBatchUDP(src, localPort, payloads)
+	}
+
+	msgs := make([]ipv4.Message, len(payloads))
+	for i, p := range payloads {
+		dst, err := netip.ParseAddr(p.DstIP)
+		if err != nil {
+			return 0, fmt.Errorf("failed to parse destination IP %d: %w", i, err)
+		}
+		data, err := c.codec.Encode(src, dst, localPort, p.DstPort, p.Data, IPHeaderOpts{TTL: c.ttl, TOS: c.tos, Flag: c.ipv4Flag})
+		if err != nil {
+			return 0, fmt.Errorf("failed to encode packet %d: %w", i, err)
+		}
+		msgs[i] = ipv4.Message{
+			Buffers: [][]byte{data},
+			Addr:    &net.IPAddr{IP: net.ParseIP(p.DstIP)},
+		}
+	}
+
+	return c.writeBatch(msgs)
+}
+
+// writeBatchUDP is the UDPCodec fast path of WriteBatchToIP: it checks
+// out one pooled gopacket.SerializeBuffer per payload, builds the
+// IPv4/UDP packet into it directly, and returns every buffer to the pool
+// once writeBatch has handed the encoded bytes to the kernel.
+func (c *DualConn) writeBatchUDP(src netip.Addr, localPort uint16, payloads []Payload) (int, error) {
+	msgs := make([]ipv4.Message, len(payloads))
+	bufs := make([]gopacket.SerializeBuffer, len(payloads))
+	defer func() {
+		for _, buf := range bufs {
+			if buf != nil {
+				serializeBufPool.Put(buf)
+			}
+		}
+	}()
+
+	for i, p := range payloads {
+		dst, err := netip.ParseAddr(p.DstIP)
+		if err != nil {
+			return 0, fmt.Errorf("failed to parse destination IP %d: %w", i, err)
+		}
+
+		buf := serializeBufPool.Get().(gopacket.SerializeBuffer)
+		bufs[i] = buf
+
+		data, err := encodeUDPInto(buf, src, dst, localPort, p.DstPort, p.Data, IPHeaderOpts{TTL: c.ttl, TOS: c.tos, Flag: c.ipv4Flag})
+		if err != nil {
+			return 0, fmt.Errorf("failed to encode packet %d: %w", i, err)
+		}
+
+		msgs[i] = ipv4.Message{
+			Buffers: [][]byte{data},
+			Addr:    &net.IPAddr{IP: net.ParseIP(p.DstIP)},
+		}
+	}
+
+	return c.writeBatch(msgs)
+}
+
+// encodeUDPInto builds a raw IPv4/UDP packet into buf, which callers
+// check out of and return to serializeBufPool. The returned slice
+// aliases buf's backing array and is only valid until buf is reused.
+func encodeUDPInto(buf gopacket.SerializeBuffer, src, dst netip.Addr, srcPort, dstPort uint16, payload []byte, hdr IPHeaderOpts) ([]byte, error) {
+	buf.Clear()
+
+	ip4 := &layers.IPv4{
+		Version:  4,
+		TTL:      hdr.TTL,
+		TOS:      hdr.TOS,
+		Flags:    layers.IPv4Flag(hdr.Flag),
+		Protocol: layers.IPProtocolUDP,
+		SrcIP:    net.ParseIP(src.String()),
+		DstIP:    net.ParseIP(dst.String()),
+	}
+	udp := &layers.UDP{
+		SrcPort: layers.UDPPort(srcPort),
+		DstPort: layers.UDPPort(dstPort),
+	}
+	if err := udp.SetNetworkLayerForChecksum(ip4); err != nil {
+		return nil, err
+	}
+
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	if err := gopacket.SerializeLayers(buf, opts, ip4, udp, gopacket.Payload(payload)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// ReadBatch reads up to len(msgs) packets from the receive socket in a
+// single batch. On Linux this delegates to ipv4.PacketConn.ReadBatch,
+// which is backed by recvmmsg. It returns errNotUDPCodec unless the
+// connection uses UDPCodec (the default).
+func (c *DualConn) ReadBatch(msgs []ipv4.Message, flags int) (int, error) {
+	if c.recvPktConn == nil {
+		return 0, errNotUDPCodec
+	}
+	return c.recvPktConn.ReadBatch(msgs, flags)
+}
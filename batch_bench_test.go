@@ -0,0 +1,30 @@
+package dualconn
+
+import "testing"
+
+func benchmarkWriteBatch(b *testing.B, batchSize int) {
+	conn, err := NewDualConn("127.0.0.1", 0)
+	if err != nil {
+		b.Fatalf("failed to create DualConn: %v", err)
+	}
+	defer conn.Close()
+
+	payloads := make([]Payload, batchSize)
+	for i := range payloads {
+		payloads[i] = Payload{DstIP: "127.0.0.1", DstPort: 30000, Data: make([]byte, 64)}
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := conn.WriteBatchToIP("127.0.0.1", 20000, payloads); err != nil {
+			b.Fatalf("WriteBatchToIP: %v", err)
+		}
+	}
+	b.StopTimer()
+
+	b.ReportMetric(float64(batchSize)*float64(b.N)/b.Elapsed().Seconds(), "pps")
+}
+
+func BenchmarkWriteBatch1(b *testing.B)  { benchmarkWriteBatch(b, 1) }
+func BenchmarkWriteBatch16(b *testing.B) { benchmarkWriteBatch(b, 16) }
+func BenchmarkWriteBatch64(b *testing.B) { benchmarkWriteBatch(b, 64) }
@@ -0,0 +1,10 @@
+//go:build linux
+
+package dualconn
+
+import "golang.org/x/net/ipv4"
+
+// writeBatch sends msgs in a single sendmmsg(2) call.
+func (c *DualConn) writeBatch(msgs []ipv4.Message) (int, error) {
+	return c.sendConn.WriteBatch(msgs, 0)
+}
@@ -0,0 +1,28 @@
+//go:build !linux
+
+package dualconn
+
+import (
+	"net"
+
+	"golang.org/x/net/ipv4"
+)
+
+// writeBatch has no sendmmsg equivalent outside Linux, so it falls back
+// to one WriteToIP call per message.
+func (c *DualConn) writeBatch(msgs []ipv4.Message) (int, error) {
+	sent := 0
+	for _, m := range msgs {
+		ipAddr, ok := m.Addr.(*net.IPAddr)
+		if !ok {
+			continue
+		}
+		for _, b := range m.Buffers {
+			if _, err := c.sendConn.WriteToIP(b, ipAddr); err != nil {
+				return sent, err
+			}
+		}
+		sent++
+	}
+	return sent, nil
+}
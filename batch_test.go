@@ -0,0 +1,55 @@
+package dualconn
+
+import (
+	"net/netip"
+	"testing"
+)
+
+// TestWriteBatchToIPReportsZeroSentOnEncodeError checks that a mid-batch
+// encode/parse failure reports 0 packets sent, not the index of the
+// failing payload: writeBatch (the actual sendmmsg call) never runs
+// before an encode error, so the earlier payloads were only encoded,
+// never sent.
+func TestWriteBatchToIPReportsZeroSentOnEncodeError(t *testing.T) {
+	c := &DualConn{
+		codec:   UDPCodec{},
+		localIP: "127.0.0.1",
+		ttl:     64,
+	}
+
+	payloads := []Payload{
+		{DstIP: "127.0.0.1", DstPort: 1234, Data: []byte("ok")},
+		{DstIP: "not-an-ip", DstPort: 1234, Data: []byte("bad")},
+	}
+
+	n, err := c.WriteBatchToIP("", 1111, payloads)
+	if err == nil {
+		t.Fatal("expected an error for the malformed destination IP")
+	}
+	if n != 0 {
+		t.Errorf("n = %d, want 0 (nothing was ever handed to writeBatch)", n)
+	}
+}
+
+// TestWriteBatchUDPReportsZeroSentOnEncodeError is the same check
+// against the UDPCodec fast path directly.
+func TestWriteBatchUDPReportsZeroSentOnEncodeError(t *testing.T) {
+	c := &DualConn{
+		codec:   UDPCodec{},
+		localIP: "127.0.0.1",
+		ttl:     64,
+	}
+
+	payloads := []Payload{
+		{DstIP: "127.0.0.1", DstPort: 1234, Data: []byte("ok")},
+		{DstIP: "not-an-ip", DstPort: 1234, Data: []byte("bad")},
+	}
+
+	n, err := c.writeBatchUDP(netip.MustParseAddr("127.0.0.1"), 1111, payloads)
+	if err == nil {
+		t.Fatal("expected an error for the malformed destination IP")
+	}
+	if n != 0 {
+		t.Errorf("n = %d, want 0 (nothing was ever handed to writeBatch)", n)
+	}
+}
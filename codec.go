@@ -0,0 +1,202 @@
+package dualconn
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+
+	"github.com/smallnest/gopacket"
+	"github.com/smallnest/gopacket/layers"
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+)
+
+// IPHeaderOpts carries the IPv4 header fields a codec needs to build its
+// packet, mirroring the per-connection settings configured via SetTTL,
+// SetTOS and SetIPv4Flag.
+type IPHeaderOpts struct {
+	TTL  uint8
+	TOS  uint8
+	Flag IPv4Flag
+}
+
+// L4Codec encodes the layer-4 protocol carried by a DualConn and opens
+// the cooked socket used to receive its replies. It is what lets
+// DualConn's raw-send/cooked-receive plumbing be reused for protocols
+// other than UDP.
+type L4Codec interface {
+	// Protocol returns the IP protocol number this codec encodes, e.g.
+	// 17 for UDP, 1 for ICMP, 6 for TCP.
+	Protocol() int
+
+	// Encode builds a full IP+L4 packet carrying payload from src to
+	// dst using the given header options.
+	Encode(src, dst netip.Addr, srcPort, dstPort uint16, payload []byte, hdr IPHeaderOpts) ([]byte, error)
+
+	// Listen opens the cooked receive socket this codec expects replies
+	// on.
+	Listen(localIP string, port int) (net.PacketConn, error)
+}
+
+// UDPCodec is the default L4Codec, matching DualConn's original
+// UDP-over-raw-IP behavior.
+type UDPCodec struct{}
+
+// Protocol returns the UDP protocol number.
+func (UDPCodec) Protocol() int { return 17 }
+
+// Encode builds a raw IPv4/UDP packet.
+func (UDPCodec) Encode(src, dst netip.Addr, srcPort, dstPort uint16, payload []byte, hdr IPHeaderOpts) ([]byte, error) {
+	return EncodeIPPacket(src.String(), dst.String(), srcPort, dstPort, payload, hdr.TTL, hdr.TOS, layers.IPv4Flag(hdr.Flag))
+}
+
+// EncodeIPPacket builds a raw IPv4/UDP packet from srcIP:srcPort to
+// dstIP:dstPort carrying payload. It is the single-packet counterpart of
+// encodeUDPInto, used by callers such as DualConnOnInterface that don't
+// go through DualConn's pooled batch path.
+func EncodeIPPacket(srcIP, dstIP string, srcPort, dstPort uint16, payload []byte, ttl, tos uint8, flag layers.IPv4Flag) ([]byte, error) {
+	ip4 := &layers.IPv4{
+		Version:  4,
+		TTL:      ttl,
+		TOS:      tos,
+		Flags:    flag,
+		Protocol: layers.IPProtocolUDP,
+		SrcIP:    net.ParseIP(srcIP),
+		DstIP:    net.ParseIP(dstIP),
+	}
+	udp := &layers.UDP{
+		SrcPort: layers.UDPPort(srcPort),
+		DstPort: layers.UDPPort(dstPort),
+	}
+	if err := udp.SetNetworkLayerForChecksum(ip4); err != nil {
+		return nil, err
+	}
+
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	if err := gopacket.SerializeLayers(buf, opts, ip4, udp, gopacket.Payload(payload)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Listen opens a plain UDP socket to receive replies on. It uses "udp4"
+// rather than "udp" because DualConn's send side is IPv4-only (see
+// NewDualConn's ipv4.RawConn); "udp" with a wildcard IPv4 address would
+// otherwise make Go open a dual-stack AF_INET6 socket that claims the
+// port for both families, conflicting with DualConn6's "udp6" recv
+// socket when both are opened on the same port by DualStackConn.
+func (UDPCodec) Listen(localIP string, port int) (net.PacketConn, error) {
+	return net.ListenUDP("udp4", &net.UDPAddr{IP: net.ParseIP(localIP), Port: port})
+}
+
+// ICMPCodec sends ICMP echo requests, mirroring net.ListenIP("ip4:icmp", …)
+// usage for latency probes and path-MTU discovery tools.
+type ICMPCodec struct {
+	// ID and Seq identify the echo request/reply pair, as in ping.
+	ID  int
+	Seq int
+}
+
+// Protocol returns the ICMP protocol number.
+func (ICMPCodec) Protocol() int { return 1 }
+
+// Encode builds a raw IPv4/ICMP echo request packet.
+func (c ICMPCodec) Encode(src, dst netip.Addr, srcPort, dstPort uint16, payload []byte, hdr IPHeaderOpts) ([]byte, error) {
+	msg := icmp.Message{
+		Type: ipv4.ICMPTypeEcho,
+		Code: 0,
+		Body: &icmp.Echo{ID: c.ID, Seq: c.Seq, Data: payload},
+	}
+	icmpBytes, err := msg.Marshal(nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal ICMP echo: %w", err)
+	}
+	return encodeIPOnly(src.String(), dst.String(), layers.IPProtocolICMPv4, icmpBytes, hdr.TTL, hdr.TOS, layers.IPv4Flag(hdr.Flag))
+}
+
+// Listen opens an ICMP listener to receive echo replies on.
+func (ICMPCodec) Listen(localIP string, port int) (net.PacketConn, error) {
+	return icmp.ListenPacket("ip4:icmp", localIP)
+}
+
+// TCPCodec emits raw TCP SYN segments for connectionless scanners; it
+// never completes a handshake, so replies are read from the raw ip:tcp
+// socket its Listen opens.
+type TCPCodec struct {
+	Seq uint32
+}
+
+// Protocol returns the TCP protocol number.
+func (TCPCodec) Protocol() int { return 6 }
+
+// Encode builds a raw IPv4/TCP SYN packet.
+func (c TCPCodec) Encode(src, dst netip.Addr, srcPort, dstPort uint16, payload []byte, hdr IPHeaderOpts) ([]byte, error) {
+	ip4 := &layers.IPv4{
+		Version:  4,
+		TTL:      hdr.TTL,
+		TOS:      hdr.TOS,
+		Flags:    layers.IPv4Flag(hdr.Flag),
+		Protocol: layers.IPProtocolTCP,
+		SrcIP:    net.ParseIP(src.String()),
+		DstIP:    net.ParseIP(dst.String()),
+	}
+	tcp := &layers.TCP{
+		SrcPort: layers.TCPPort(srcPort),
+		DstPort: layers.TCPPort(dstPort),
+		Seq:     c.Seq,
+		SYN:     true,
+		Window:  65535,
+	}
+	if err := tcp.SetNetworkLayerForChecksum(ip4); err != nil {
+		return nil, err
+	}
+
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	if err := gopacket.SerializeLayers(buf, opts, ip4, tcp, gopacket.Payload(payload)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Listen opens a raw ip:tcp socket to receive SYN-ACK/RST replies on.
+func (TCPCodec) Listen(localIP string, port int) (net.PacketConn, error) {
+	return net.ListenPacket("ip:tcp", localIP)
+}
+
+// encodeIPOnly builds a raw IPv4 packet around an already-encoded L4
+// payload, for codecs such as ICMPCodec that build their own L4 header.
+func encodeIPOnly(srcIP, dstIP string, proto layers.IPProtocol, payload []byte, ttl, tos uint8, flag layers.IPv4Flag) ([]byte, error) {
+	ip4 := &layers.IPv4{
+		Version:  4,
+		TTL:      ttl,
+		TOS:      tos,
+		Flags:    flag,
+		Protocol: proto,
+		SrcIP:    net.ParseIP(srcIP),
+		DstIP:    net.ParseIP(dstIP),
+	}
+
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	if err := gopacket.SerializeLayers(buf, opts, ip4, gopacket.Payload(payload)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Option configures optional behavior on a DualConn at construction time.
+type Option func(*dualConnConfig)
+
+type dualConnConfig struct {
+	codec L4Codec
+}
+
+// WithCodec selects the L4Codec a DualConn uses to encode outbound
+// packets and to open its receive socket. The default is UDPCodec{}.
+func WithCodec(codec L4Codec) Option {
+	return func(cfg *dualConnConfig) {
+		cfg.codec = codec
+	}
+}
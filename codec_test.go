@@ -0,0 +1,92 @@
+package dualconn
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+
+	"github.com/smallnest/gopacket"
+	"github.com/smallnest/gopacket/layers"
+)
+
+func TestUDPCodecEncode(t *testing.T) {
+	src := netip.MustParseAddr("1.2.3.4")
+	dst := netip.MustParseAddr("5.6.7.8")
+
+	data, err := UDPCodec{}.Encode(src, dst, 1111, 53, []byte("hello"), IPHeaderOpts{TTL: 64})
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+
+	pkt := gopacket.NewPacket(data, layers.LayerTypeIPv4, gopacket.Default)
+	ip4, ok := pkt.Layer(layers.LayerTypeIPv4).(*layers.IPv4)
+	if !ok {
+		t.Fatal("expected an IPv4 layer")
+	}
+	if ip4.Protocol != layers.IPProtocolUDP {
+		t.Errorf("protocol = %v, want UDP", ip4.Protocol)
+	}
+	if !ip4.DstIP.Equal(net.ParseIP(dst.String())) {
+		t.Errorf("dst IP = %v, want %v", ip4.DstIP, dst)
+	}
+
+	udp, ok := pkt.Layer(layers.LayerTypeUDP).(*layers.UDP)
+	if !ok {
+		t.Fatal("expected a UDP layer")
+	}
+	if udp.DstPort != 53 {
+		t.Errorf("dst port = %d, want 53", udp.DstPort)
+	}
+	if string(udp.Payload) != "hello" {
+		t.Errorf("payload = %q, want %q", udp.Payload, "hello")
+	}
+}
+
+func TestICMPCodecEncode(t *testing.T) {
+	src := netip.MustParseAddr("1.2.3.4")
+	dst := netip.MustParseAddr("5.6.7.8")
+
+	data, err := ICMPCodec{ID: 42, Seq: 1}.Encode(src, dst, 0, 0, []byte("ping"), IPHeaderOpts{TTL: 64})
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+
+	pkt := gopacket.NewPacket(data, layers.LayerTypeIPv4, gopacket.Default)
+	ip4, ok := pkt.Layer(layers.LayerTypeIPv4).(*layers.IPv4)
+	if !ok {
+		t.Fatal("expected an IPv4 layer")
+	}
+	if ip4.Protocol != layers.IPProtocolICMPv4 {
+		t.Errorf("protocol = %v, want ICMPv4", ip4.Protocol)
+	}
+}
+
+func TestTCPCodecEncode(t *testing.T) {
+	src := netip.MustParseAddr("1.2.3.4")
+	dst := netip.MustParseAddr("5.6.7.8")
+
+	data, err := TCPCodec{Seq: 100}.Encode(src, dst, 1111, 80, nil, IPHeaderOpts{TTL: 64})
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+
+	pkt := gopacket.NewPacket(data, layers.LayerTypeIPv4, gopacket.Default)
+	ip4, ok := pkt.Layer(layers.LayerTypeIPv4).(*layers.IPv4)
+	if !ok {
+		t.Fatal("expected an IPv4 layer")
+	}
+	if ip4.Protocol != layers.IPProtocolTCP {
+		t.Errorf("protocol = %v, want TCP", ip4.Protocol)
+	}
+
+	tcp, ok := pkt.Layer(layers.LayerTypeTCP).(*layers.TCP)
+	if !ok {
+		t.Fatal("expected a TCP layer")
+	}
+	if !tcp.SYN {
+		t.Error("expected SYN to be set")
+	}
+	if tcp.DstPort != 80 {
+		t.Errorf("dst port = %d, want 80", tcp.DstPort)
+	}
+}
@@ -1,26 +1,37 @@
 package dualconn
 
 import (
+	"errors"
 	"fmt"
 	"net"
 	"net/netip"
 	"time"
 
-	"github.com/smallnest/gopacket/layers"
 	qbpf "github.com/smallnest/qianmo/bpf"
 	"golang.org/x/net/bpf"
 	"golang.org/x/net/ipv4"
 )
 
+// errNotUDPCodec is returned by the methods below that only make sense
+// when the connection's receive socket is the plain *net.UDPConn opened
+// by UDPCodec (the default); they return it when the connection was built
+// with WithCodec(ICMPCodec{}) or WithCodec(TCPCodec{}), which leaves
+// recvConn and recvPktConn nil.
+var errNotUDPCodec = errors.New("dualconn: operation requires a connection built with UDPCodec")
+
 // IPv4Flag represents the flags in an IPv4 header.
 type IPv4Flag uint8
 
-// DualConn represents a connection that combines an IPv4 raw connection and a UDP connection.
-// It is used to send UDP packets with raw IP headers.
-// And receive UDP packets with net.recvConn.
+// DualConn represents a connection that combines an IPv4 raw connection and a cooked connection.
+// It is used to send packets with raw IP headers, built by an L4Codec (UDP by default).
+// And receive replies on the cooked socket the codec opens.
 type DualConn struct {
-	sendConn *ipv4.RawConn // IPv4 raw connection for sending UDP packets
-	recvConn *net.UDPConn  // UDP connection for receiving UDP packets
+	sendConn    *ipv4.RawConn    // IPv4 raw connection for sending packets
+	recvPC      net.PacketConn   // cooked socket opened by codec for receiving replies
+	recvConn    *net.UDPConn     // recvPC narrowed to *net.UDPConn; nil unless codec is UDPCodec
+	recvPktConn *ipv4.PacketConn // wraps recvConn for multicast membership and control messages; nil unless codec is UDPCodec
+
+	codec L4Codec
 
 	localIP string
 
@@ -29,16 +40,25 @@ type DualConn struct {
 	tos      uint8
 	ttl      uint8
 	ipv4Flag IPv4Flag
+
+	cmEnabled bool // whether ReadFromCM has already requested control messages
 }
 
 // NewDualConn creates a new DualConn.
-// It creates an IPv4 raw connection for sending UDP packets with raw IP headers.
-// And a UDP connection for receiving UDP packets.
+// It creates an IPv4 raw connection for sending packets with raw IP
+// headers, and a cooked connection for receiving replies. By default it
+// speaks UDP; pass WithCodec to ride the same raw-send/cooked-receive
+// plumbing over ICMP or TCP instead.
 //
-// @param localAddr: the local IP address to bind for sending UDP packets
-// @param port: the local port to bind for receiving UDP packets
-func NewDualConn(localAddr string, port int) (*DualConn, error) {
-	pconn, err := net.ListenPacket("ip:udp", localAddr)
+// @param localAddr: the local IP address to bind for sending packets
+// @param port: the local port to bind for receiving replies
+func NewDualConn(localAddr string, port int, opts ...Option) (*DualConn, error) {
+	cfg := dualConnConfig{codec: UDPCodec{}}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	pconn, err := net.ListenPacket(fmt.Sprintf("ip4:%d", cfg.codec.Protocol()), localAddr)
 	if err != nil {
 		return nil, err
 	}
@@ -63,21 +83,27 @@ func NewDualConn(localAddr string, port int) (*DualConn, error) {
 		return nil, err
 	}
 
-	uconn, err := net.ListenUDP("udp", &net.UDPAddr{
-		IP:   net.ParseIP(localAddr),
-		Port: port,
-	})
+	recvPC, err := cfg.codec.Listen(localAddr, port)
 	if err != nil {
 		_ = sendConn.Close()
 		return nil, err
 	}
 
-	return &DualConn{
+	c := &DualConn{
 		sendConn: sendConn,
-		recvConn: uconn,
+		recvPC:   recvPC,
+		codec:    cfg.codec,
+		localIP:  localAddr,
 
 		ttl: 64,
-	}, nil
+	}
+
+	if uconn, ok := recvPC.(*net.UDPConn); ok {
+		c.recvConn = uconn
+		c.recvPktConn = ipv4.NewPacketConn(uconn)
+	}
+
+	return c, nil
 }
 
 func createDropAllBPF() []bpf.Instruction {
@@ -106,15 +132,29 @@ func (c *DualConn) SetIPv4Flag(flag IPv4Flag) {
 	c.ipv4Flag = flag
 }
 
-// WriteToIP writes UDP data to the specified destination IP and port.
+// WriteToIP encodes payload with the connection's codec (UDP by default)
+// and writes it to the specified destination IP and port. It has no
+// per-call outbound-interface parameter: source-interface selection is
+// connection-scoped via SetMulticastInterface, so a single DualConn
+// can't source packets to two destinations over two different
+// interfaces in the same call.
 func (c *DualConn) WriteToIP(payload []byte, localIP, remoteIP string, localPort, remotePort uint16) (int, error) {
 	if localIP == "" {
 		localIP = c.localIP
 	}
 
-	data, err := EncodeIPPacket(localIP, remoteIP, localPort, remotePort, payload, c.ttl, c.tos, layers.IPv4Flag(c.ipv4Flag))
+	src, err := netip.ParseAddr(localIP)
 	if err != nil {
-		return 0, fmt.Errorf("failed to encode IP packet: %w", err)
+		return 0, fmt.Errorf("failed to parse local IP: %w", err)
+	}
+	dst, err := netip.ParseAddr(remoteIP)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse remote IP: %w", err)
+	}
+
+	data, err := c.codec.Encode(src, dst, localPort, remotePort, payload, IPHeaderOpts{TTL: c.ttl, TOS: c.tos, Flag: c.ipv4Flag})
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode packet: %w", err)
 	}
 
 	if c.timeout > 0 {
@@ -127,36 +167,121 @@ func (c *DualConn) WriteToIP(payload []byte, localIP, remoteIP string, localPort
 	return n, nil
 }
 
-// ReadFrom reads a UDP packet from the connection.
-// It returns the number of bytes read, the source address and the error.
+// Read reads a reply from the connection's receive socket.
 func (c *DualConn) Read(b []byte) (int, error) {
-	return c.recvConn.Read(b)
+	n, _, err := c.recvPC.ReadFrom(b)
+	return n, err
 }
 
-// ReadFrom reads a UDP packet from the connection.
+// ReadFrom reads a reply from the connection's receive socket.
 // It returns the number of bytes read, the source address and the error.
 func (c *DualConn) ReadFrom(b []byte) (n int, addr net.Addr, err error) {
-	return c.recvConn.ReadFrom(b)
+	return c.recvPC.ReadFrom(b)
 }
 
-// ReadFromUDP reads a UDP packet from the connection.
+// ReadFromUDPAddrPort reads a UDP packet from the connection. It returns
+// errNotUDPCodec unless the connection uses UDPCodec (the default).
 // It returns the number of bytes read, the source address and the error.
 func (c *DualConn) ReadFromUDPAddrPort(b []byte) (n int, addr netip.AddrPort, err error) {
+	if c.recvConn == nil {
+		return 0, netip.AddrPort{}, errNotUDPCodec
+	}
 	return c.recvConn.ReadFromUDPAddrPort(b)
 }
 
-// ReadFromUDP reads a UDP packet from the connection.
+// ReadMsgUDPAddrPort reads a UDP packet from the connection. It returns
+// errNotUDPCodec unless the connection uses UDPCodec (the default).
 // It returns the number of bytes read, the oob data, the flags, the source address and the error.
 func (c *DualConn) ReadMsgUDPAddrPort(b, oob []byte) (n, oobn, flags int, addr netip.AddrPort, err error) {
+	if c.recvConn == nil {
+		return 0, 0, 0, netip.AddrPort{}, errNotUDPCodec
+	}
 	return c.recvConn.ReadMsgUDPAddrPort(b, oob)
 }
 
-// ReadFromUDP reads a UDP packet from the connection.
+// ReadFromUDP reads a UDP packet from the connection. It returns
+// errNotUDPCodec unless the connection uses UDPCodec (the default).
 // It returns the number of bytes read, the source address and the error.
 func (c *DualConn) ReadFromUDP(b []byte) (n int, addr *net.UDPAddr, err error) {
+	if c.recvConn == nil {
+		return 0, nil, errNotUDPCodec
+	}
 	return c.recvConn.ReadFromUDP(b)
 }
 
+// JoinGroup joins the multicast group on the given interface, so the
+// receive side accepts datagrams sent to that group. ifi may be nil to
+// let the system choose the interface. It returns errNotUDPCodec unless
+// the connection uses UDPCodec (the default).
+func (c *DualConn) JoinGroup(ifi *net.Interface, group net.Addr) error {
+	if c.recvPktConn == nil {
+		return errNotUDPCodec
+	}
+	return c.recvPktConn.JoinGroup(ifi, group)
+}
+
+// LeaveGroup leaves a multicast group previously joined with JoinGroup.
+// It returns errNotUDPCodec unless the connection uses UDPCodec (the
+// default).
+func (c *DualConn) LeaveGroup(ifi *net.Interface, group net.Addr) error {
+	if c.recvPktConn == nil {
+		return errNotUDPCodec
+	}
+	return c.recvPktConn.LeaveGroup(ifi, group)
+}
+
+// SetMulticastInterface sets the default outgoing multicast interface on
+// both the receive socket and the raw send socket, making
+// source-interface selection deterministic. The interface multicast
+// datagrams are received on is chosen by JoinGroup's ifi. It returns
+// errNotUDPCodec unless the connection uses UDPCodec (the default).
+func (c *DualConn) SetMulticastInterface(ifi *net.Interface) error {
+	if c.recvPktConn == nil {
+		return errNotUDPCodec
+	}
+	if err := c.recvPktConn.SetMulticastInterface(ifi); err != nil {
+		return err
+	}
+	return c.sendConn.SetMulticastInterface(ifi)
+}
+
+// SetMulticastTTL sets the TTL used for outgoing multicast packets.
+func (c *DualConn) SetMulticastTTL(ttl int) error {
+	return c.sendConn.SetMulticastTTL(ttl)
+}
+
+// SetMulticastLoopback controls whether multicast packets sent from this
+// connection are looped back to local listeners. It returns
+// errNotUDPCodec unless the connection uses UDPCodec (the default).
+func (c *DualConn) SetMulticastLoopback(on bool) error {
+	if c.recvPktConn == nil {
+		return errNotUDPCodec
+	}
+	if err := c.recvPktConn.SetMulticastLoopback(on); err != nil {
+		return err
+	}
+	return c.sendConn.SetMulticastLoopback(on)
+}
+
+// ReadFromCM reads a UDP packet along with its IPv4 control message,
+// exposing the interface index, destination address and TTL of the
+// incoming datagram. It is useful for mDNS-style responders and SSM
+// receivers that need to know which interface or group a packet arrived
+// on. It returns errNotUDPCodec unless the connection uses UDPCodec (the
+// default).
+func (c *DualConn) ReadFromCM(b []byte) (n int, cm *ipv4.ControlMessage, src net.Addr, err error) {
+	if c.recvPktConn == nil {
+		return 0, nil, nil, errNotUDPCodec
+	}
+	if !c.cmEnabled {
+		if err := c.recvPktConn.SetControlMessage(ipv4.FlagInterface|ipv4.FlagDst|ipv4.FlagTTL, true); err != nil {
+			return 0, nil, nil, err
+		}
+		c.cmEnabled = true
+	}
+	return c.recvPktConn.ReadFrom(b)
+}
+
 // SetBBF sets the BPF filter for the connection.
 func (c *DualConn) SetBBF(filter []bpf.RawInstruction) error {
 	return c.sendConn.SetBPF(filter)
@@ -172,5 +297,5 @@ func (c *DualConn) SetBBFExpr(expr string) error {
 // Close closes the connection.
 func (c *DualConn) Close() error {
 	_ = c.sendConn.Close()
-	return c.recvConn.Close()
+	return c.recvPC.Close()
 }
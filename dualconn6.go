@@ -0,0 +1,132 @@
+package dualconn
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"time"
+
+	"golang.org/x/net/ipv6"
+)
+
+// DualConn6 is the IPv6 counterpart of DualConn. Unlike DualConn, it
+// cannot send with a hand-built IP header: golang.org/x/net/ipv6 has no
+// IP_HDRINCL-style raw send path (ipv6.Header is parse-only, and there is
+// no ipv6.NewRawConn), so DualConn6 sends and receives on a single real
+// "udp6" socket, wrapped in an ipv6.PacketConn so per-write hop
+// limit/traffic class can be set via control messages the way the
+// package is designed to be used.
+type DualConn6 struct {
+	conn    *net.UDPConn
+	pktConn *ipv6.PacketConn
+
+	localIP string
+
+	timeout time.Duration
+
+	trafficClass int
+	hopLimit     int
+	flowLabel    uint32
+}
+
+// NewDualConn6 creates a new DualConn6.
+// It opens a single "udp6" socket used for both sending and receiving
+// UDP packets, wrapped in an ipv6.PacketConn so SetTrafficClass and
+// SetHopLimit can be applied per write via control messages.
+//
+// @param localAddr: the local IPv6 address to bind
+// @param port: the local port to bind
+func NewDualConn6(localAddr string, port int) (*DualConn6, error) {
+	// "udp6" (rather than "udp") makes the kernel set IPV6_V6ONLY on this
+	// socket, so it doesn't also claim the IPv4 wildcard on port; without
+	// it, DualStackConn's "::" bind and DualConn's "0.0.0.0" bind on the
+	// same port race on EADDRINUSE depending on bind order.
+	uconn, err := net.ListenUDP("udp6", &net.UDPAddr{
+		IP:   net.ParseIP(localAddr),
+		Port: port,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &DualConn6{
+		conn:    uconn,
+		pktConn: ipv6.NewPacketConn(uconn),
+		localIP: localAddr,
+
+		hopLimit: 64,
+	}, nil
+}
+
+// SetTimeout sets the timeout for the connection.
+func (c *DualConn6) SetTimeout(timeout time.Duration) {
+	c.timeout = timeout
+}
+
+// SetTrafficClass sets the traffic class for the connection, the IPv6
+// equivalent of IPv4's TOS.
+func (c *DualConn6) SetTrafficClass(tc uint8) {
+	c.trafficClass = int(tc)
+}
+
+// SetHopLimit sets the hop limit for the connection, the IPv6 equivalent
+// of IPv4's TTL.
+func (c *DualConn6) SetHopLimit(hopLimit uint8) {
+	c.hopLimit = int(hopLimit)
+}
+
+// SetFlowLabel sets the 20-bit flow label for the connection. Unlike
+// TrafficClass and HopLimit, flow labels can't be conveyed through
+// ipv6.ControlMessage on a regular UDP socket (golang.org/x/net/ipv6
+// doesn't expose IPV6_FLOWINFO_SEND), so this is stored but currently
+// has no effect on outgoing packets.
+func (c *DualConn6) SetFlowLabel(flowLabel uint32) {
+	c.flowLabel = flowLabel & 0xfffff
+}
+
+// WriteToIP writes payload as UDP data from local to dst, both given as
+// netip.AddrPort so callers don't have to format or parse IP strings by
+// hand. If local.Addr() is valid, it's passed as the source address via
+// the write's control message (useful when the socket is bound to the
+// wildcard address); local.Port() is ignored since the socket's source
+// port is fixed at bind time.
+func (c *DualConn6) WriteToIP(payload []byte, local, dst netip.AddrPort) (int, error) {
+	cm := &ipv6.ControlMessage{
+		TrafficClass: c.trafficClass,
+		HopLimit:     c.hopLimit,
+	}
+	if local.IsValid() && local.Addr().IsValid() {
+		cm.Src = net.IP(local.Addr().AsSlice())
+	}
+
+	if c.timeout > 0 {
+		_ = c.conn.SetWriteDeadline(time.Now().Add(c.timeout))
+	}
+	n, err := c.pktConn.WriteTo(payload, cm, &net.UDPAddr{IP: net.ParseIP(dst.Addr().String()), Port: int(dst.Port())})
+	if err != nil {
+		return 0, fmt.Errorf("failed to write to IP: %w", err)
+	}
+	return n, nil
+}
+
+// Read reads a UDP packet from the connection.
+func (c *DualConn6) Read(b []byte) (int, error) {
+	return c.conn.Read(b)
+}
+
+// ReadFrom reads a UDP packet from the connection.
+// It returns the number of bytes read, the source address and the error.
+func (c *DualConn6) ReadFrom(b []byte) (n int, addr net.Addr, err error) {
+	return c.conn.ReadFrom(b)
+}
+
+// ReadFromAddrPort reads a UDP packet from the connection.
+// It returns the number of bytes read, the source address and the error.
+func (c *DualConn6) ReadFromAddrPort(b []byte) (n int, addr netip.AddrPort, err error) {
+	return c.conn.ReadFromUDPAddrPort(b)
+}
+
+// Close closes the connection.
+func (c *DualConn6) Close() error {
+	return c.conn.Close()
+}
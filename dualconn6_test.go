@@ -0,0 +1,45 @@
+package dualconn
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+// TestDualConn6WriteToIPRoundTrip sends a packet with DualConn6.WriteToIP
+// to a real net.ListenUDP("udp6", …) peer and checks it actually arrives
+// as a well-formed UDP datagram, not a user-space buffer the kernel never
+// parses. This guards against DualConn6 silently building packets that
+// can't reach any real peer.
+func TestDualConn6WriteToIPRoundTrip(t *testing.T) {
+	peer, err := net.ListenUDP("udp6", &net.UDPAddr{IP: net.ParseIP("::1")})
+	if err != nil {
+		t.Skipf("no IPv6 loopback in this sandbox: %v", err)
+	}
+	defer peer.Close()
+	peerAddr := peer.LocalAddr().(*net.UDPAddr)
+
+	c, err := NewDualConn6("::1", 0)
+	if err != nil {
+		t.Fatalf("NewDualConn6: %v", err)
+	}
+	defer c.Close()
+	c.SetHopLimit(32)
+	c.SetTrafficClass(0x2e)
+
+	dst := netip.AddrPortFrom(netip.MustParseAddr("::1"), uint16(peerAddr.Port))
+	if _, err := c.WriteToIP([]byte("ping"), netip.AddrPort{}, dst); err != nil {
+		t.Fatalf("WriteToIP: %v", err)
+	}
+
+	b := make([]byte, 16)
+	_ = peer.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, _, err := peer.ReadFromUDP(b)
+	if err != nil {
+		t.Fatalf("peer ReadFromUDP: %v (packet never arrived)", err)
+	}
+	if string(b[:n]) != "ping" {
+		t.Errorf("payload = %q, want %q", b[:n], "ping")
+	}
+}
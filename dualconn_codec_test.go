@@ -0,0 +1,43 @@
+package dualconn
+
+import (
+	"errors"
+	"testing"
+)
+
+// TestNonUDPCodecMethodsReturnError builds a DualConn the way NewDualConn
+// would for a non-UDP codec (recvConn and recvPktConn left nil, since
+// recvPC isn't a *net.UDPConn) and checks that the UDP-only methods fail
+// with errNotUDPCodec instead of dereferencing a nil pointer.
+func TestNonUDPCodecMethodsReturnError(t *testing.T) {
+	c := &DualConn{codec: ICMPCodec{}}
+
+	if _, _, err := c.ReadFromUDPAddrPort(nil); !errors.Is(err, errNotUDPCodec) {
+		t.Errorf("ReadFromUDPAddrPort: got %v, want errNotUDPCodec", err)
+	}
+	if _, _, _, _, err := c.ReadMsgUDPAddrPort(nil, nil); !errors.Is(err, errNotUDPCodec) {
+		t.Errorf("ReadMsgUDPAddrPort: got %v, want errNotUDPCodec", err)
+	}
+	if _, _, err := c.ReadFromUDP(nil); !errors.Is(err, errNotUDPCodec) {
+		t.Errorf("ReadFromUDP: got %v, want errNotUDPCodec", err)
+	}
+	if err := c.JoinGroup(nil, nil); !errors.Is(err, errNotUDPCodec) {
+		t.Errorf("JoinGroup: got %v, want errNotUDPCodec", err)
+	}
+	if err := c.LeaveGroup(nil, nil); !errors.Is(err, errNotUDPCodec) {
+		t.Errorf("LeaveGroup: got %v, want errNotUDPCodec", err)
+	}
+	if err := c.SetMulticastInterface(nil); !errors.Is(err, errNotUDPCodec) {
+		t.Errorf("SetMulticastInterface: got %v, want errNotUDPCodec", err)
+	}
+	if err := c.SetMulticastLoopback(true); !errors.Is(err, errNotUDPCodec) {
+		t.Errorf("SetMulticastLoopback: got %v, want errNotUDPCodec", err)
+	}
+	if _, _, _, err := c.ReadFromCM(nil); !errors.Is(err, errNotUDPCodec) {
+		t.Errorf("ReadFromCM: got %v, want errNotUDPCodec", err)
+	}
+
+	if _, err := c.ReadBatch(nil, 0); !errors.Is(err, errNotUDPCodec) {
+		t.Errorf("ReadBatch: got %v, want errNotUDPCodec", err)
+	}
+}
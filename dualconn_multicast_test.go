@@ -0,0 +1,70 @@
+package dualconn
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"golang.org/x/net/ipv4"
+)
+
+// TestJoinLeaveGroup exercises JoinGroup/LeaveGroup against a real
+// loopback multicast socket,
+// built the same way NewDualConn wires up recvPktConn for UDPCodec. It
+// skips if the sandbox has no multicast-capable loopback interface.
+func TestJoinLeaveGroup(t *testing.T) {
+	ifi, err := net.InterfaceByName("lo")
+	if err != nil {
+		t.Skipf("no loopback interface: %v", err)
+	}
+
+	uconn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4zero})
+	if err != nil {
+		t.Fatalf("ListenUDP: %v", err)
+	}
+	defer uconn.Close()
+
+	c := &DualConn{
+		codec:       UDPCodec{},
+		recvConn:    uconn,
+		recvPktConn: ipv4.NewPacketConn(uconn),
+	}
+
+	group := &net.UDPAddr{IP: net.IPv4(224, 0, 0, 114)}
+	if err := c.JoinGroup(ifi, group); err != nil {
+		t.Skipf("JoinGroup not permitted in this sandbox: %v", err)
+	}
+	if err := c.LeaveGroup(ifi, group); err != nil {
+		t.Errorf("LeaveGroup: %v", err)
+	}
+}
+
+// TestReadFromCMEnablesControlMessagesOnce checks that the first
+// ReadFromCM call enables control messages and sets cmEnabled, the flag
+// that makes later calls skip SetControlMessage.
+func TestReadFromCMEnablesControlMessagesOnce(t *testing.T) {
+	uconn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4zero})
+	if err != nil {
+		t.Fatalf("ListenUDP: %v", err)
+	}
+	defer uconn.Close()
+
+	c := &DualConn{
+		codec:       UDPCodec{},
+		recvConn:    uconn,
+		recvPktConn: ipv4.NewPacketConn(uconn),
+		timeout:     0,
+	}
+
+	if c.cmEnabled {
+		t.Fatal("cmEnabled should start false")
+	}
+
+	b := make([]byte, 16)
+	_ = uconn.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+	_, _, _, _ = c.ReadFromCM(b)
+
+	if !c.cmEnabled {
+		t.Error("expected cmEnabled to be set to true after ReadFromCM")
+	}
+}
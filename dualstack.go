@@ -0,0 +1,99 @@
+package dualconn
+
+import (
+	"fmt"
+	"net/netip"
+)
+
+// DualStackConn transparently dispatches between an IPv4 DualConn and an
+// IPv6 DualConn6 based on the address family of the peer, so callers don't
+// have to branch on family themselves.
+type DualStackConn struct {
+	v4 *DualConn
+	v6 *DualConn6
+}
+
+// NewDualStackConn creates a DualStackConn bound to localAddr and port.
+// If localAddr parses as an IPv4 (or IPv4-in-IPv6) address, only the IPv4
+// backend is opened; if it parses as IPv6, only the IPv6 backend is
+// opened. If localAddr is empty, both backends are opened on their
+// respective wildcard addresses so WriteToIP can dispatch on whichever
+// family the destination turns out to be.
+func NewDualStackConn(localAddr string, port int) (*DualStackConn, error) {
+	if localAddr == "" {
+		v4, err := NewDualConn("0.0.0.0", port)
+		if err != nil {
+			return nil, err
+		}
+		v6, err := NewDualConn6("::", port)
+		if err != nil {
+			_ = v4.Close()
+			return nil, err
+		}
+		return &DualStackConn{v4: v4, v6: v6}, nil
+	}
+
+	addr, err := netip.ParseAddr(localAddr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse local address: %w", err)
+	}
+
+	if addr.Is4() || addr.Is4In6() {
+		v4, err := NewDualConn(localAddr, port)
+		if err != nil {
+			return nil, err
+		}
+		return &DualStackConn{v4: v4}, nil
+	}
+
+	v6, err := NewDualConn6(localAddr, port)
+	if err != nil {
+		return nil, err
+	}
+	return &DualStackConn{v6: v6}, nil
+}
+
+// WriteToIP writes payload from local to dst, dispatching to the IPv4 or
+// IPv6 backend based on dst's address family.
+func (c *DualStackConn) WriteToIP(payload []byte, local, dst netip.AddrPort) (int, error) {
+	if dst.Addr().Is4() || dst.Addr().Is4In6() {
+		if c.v4 == nil {
+			return 0, fmt.Errorf("dual-stack connection has no IPv4 backend")
+		}
+		localIP := ""
+		if local.IsValid() {
+			localIP = local.Addr().String()
+		}
+		return c.v4.WriteToIP(payload, localIP, dst.Addr().String(), local.Port(), dst.Port())
+	}
+
+	if c.v6 == nil {
+		return 0, fmt.Errorf("dual-stack connection has no IPv6 backend")
+	}
+	return c.v6.WriteToIP(payload, local, dst)
+}
+
+// ReadFromAddrPort reads a UDP packet from whichever backend is active.
+// If both backends are active, it reads from the IPv4 backend; use the
+// per-family ReadFromAddrPort methods on DualConn/DualConn6 directly to
+// read both concurrently.
+func (c *DualStackConn) ReadFromAddrPort(b []byte) (n int, addr netip.AddrPort, err error) {
+	if c.v4 != nil {
+		return c.v4.ReadFromUDPAddrPort(b)
+	}
+	return c.v6.ReadFromAddrPort(b)
+}
+
+// Close closes whichever backend(s) are active.
+func (c *DualStackConn) Close() error {
+	var err error
+	if c.v4 != nil {
+		err = c.v4.Close()
+	}
+	if c.v6 != nil {
+		if err2 := c.v6.Close(); err == nil {
+			err = err2
+		}
+	}
+	return err
+}
@@ -0,0 +1,25 @@
+package dualconn
+
+import "testing"
+
+// TestNewDualStackConnOpensBothBackendsOnSamePort checks that the v4 and
+// v6 wildcard backends opened by NewDualStackConn("", port) can share the
+// same literal port, which requires the v6 recv socket to be bound
+// IPV6_V6ONLY so it doesn't collide with the v4 wildcard bind. It skips
+// if the sandbox doesn't allow opening the raw sockets DualConn needs.
+func TestNewDualStackConnOpensBothBackendsOnSamePort(t *testing.T) {
+	const port = 17891
+
+	c, err := NewDualStackConn("", port)
+	if err != nil {
+		t.Skipf("NewDualStackConn not permitted in this sandbox: %v", err)
+	}
+	defer c.Close()
+
+	if c.v4 == nil {
+		t.Error("expected an IPv4 backend")
+	}
+	if c.v6 == nil {
+		t.Error("expected an IPv6 backend")
+	}
+}
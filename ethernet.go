@@ -0,0 +1,38 @@
+package dualconn
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/smallnest/gopacket"
+	"github.com/smallnest/gopacket/layers"
+)
+
+// encodeEthernetFrame wraps an already-encoded IP packet in an Ethernet
+// header.
+func encodeEthernetFrame(src, dst net.HardwareAddr, ipPacket []byte) ([]byte, error) {
+	eth := &layers.Ethernet{
+		SrcMAC:       src,
+		DstMAC:       dst,
+		EthernetType: layers.EthernetTypeIPv4,
+	}
+
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true}
+	if err := gopacket.SerializeLayers(buf, opts, eth, gopacket.Payload(ipPacket)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decodeUDPPayload parses an Ethernet frame and returns the payload of
+// its UDP layer.
+func decodeUDPPayload(frame []byte) ([]byte, error) {
+	pkt := gopacket.NewPacket(frame, layers.LayerTypeEthernet, gopacket.Default)
+	udpLayer := pkt.Layer(layers.LayerTypeUDP)
+	if udpLayer == nil {
+		return nil, fmt.Errorf("frame does not contain a UDP layer")
+	}
+	udp, _ := udpLayer.(*layers.UDP)
+	return udp.Payload, nil
+}
@@ -0,0 +1,49 @@
+package dualconn
+
+import (
+	"fmt"
+
+	"github.com/google/gopacket/layers"
+	qbpf "github.com/smallnest/qianmo/bpf"
+	"golang.org/x/net/bpf"
+)
+
+// CompileFilter compiles a tcpdump-style filter expression (e.g. "udp and
+// dst port 53") into a BPF program for DualConn's raw IPv4 socket, which
+// never sees a link-layer header. Unlike SetBBFExpr, which feeds
+// expressions through ParseTcpdumpFitlerData (a parser for "tcpdump -dd"
+// decimal-tuple output, not filter expressions), this uses
+// ParseTcpdumpFitlerExpr, which wraps pcap.CompileBPFFilter and reports a
+// real error instead of panicking or silently matching nothing.
+func CompileFilter(expr string) ([]bpf.RawInstruction, error) {
+	insns, err := qbpf.ParseTcpdumpFitlerExpr(layers.LinkTypeRaw, expr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile filter %q: %w", expr, err)
+	}
+	return insns, nil
+}
+
+// Filter is implemented by anything that can assemble itself into a raw
+// BPF program, so SetFilter accepts both CompileFilter's output (wrapped
+// in RawFilter) and the filter package's builder DSL.
+type Filter interface {
+	Assemble() ([]bpf.RawInstruction, error)
+}
+
+// RawFilter adapts an already-compiled BPF program, such as the output
+// of CompileFilter, to the Filter interface.
+type RawFilter []bpf.RawInstruction
+
+// Assemble returns f unchanged.
+func (f RawFilter) Assemble() ([]bpf.RawInstruction, error) {
+	return f, nil
+}
+
+// SetFilter assembles f and installs it as the send socket's BPF filter.
+func (c *DualConn) SetFilter(f Filter) error {
+	raw, err := f.Assemble()
+	if err != nil {
+		return fmt.Errorf("failed to assemble filter: %w", err)
+	}
+	return c.sendConn.SetBPF(raw)
+}
@@ -0,0 +1,133 @@
+// Package filter provides a small, composable builder for classic BPF
+// packet filters over bare IPv4 packets — the framing DualConn's raw
+// AF_INET socket (opened via net.ListenPacket("ip4:N", …)) actually
+// sees, with no link-layer header in front of it. It lets callers build
+// a DualConn.SetFilter filter programmatically and unit-test it, instead
+// of writing a tcpdump expression string and hoping it parses.
+//
+// This package is not suitable for DualConnOnInterface's AF_PACKET
+// socket, which carries a 14-byte Ethernet header in front of every IP
+// packet. CompileFilter's output assumes the same bare-IPv4 framing, so
+// that path needs a hand-written BPF program instead.
+package filter
+
+import (
+	"fmt"
+	"net"
+
+	"golang.org/x/net/bpf"
+)
+
+const (
+	// ipHeaderLen assumes no IP options (a 20-byte header), which holds
+	// for every packet DualConn itself builds.
+	ipHeaderLen   = 20
+	ipProtoOffset = 9
+	ipSrcOffset   = 12
+	ipDstOffset   = 16
+	l4HeaderOff   = ipHeaderLen
+)
+
+const (
+	protoTCP = 6
+	protoUDP = 17
+)
+
+// node is a single test in a BPF decision tree. emit returns the
+// instructions for that test; on match, control continues onMatchSkip
+// instructions past the next one, and on mismatch it continues
+// onFailSkip instructions past the next one.
+type node interface {
+	emit(onMatchSkip, onFailSkip uint8) []bpf.Instruction
+}
+
+type nodeFunc func(onMatchSkip, onFailSkip uint8) []bpf.Instruction
+
+func (f nodeFunc) emit(onMatchSkip, onFailSkip uint8) []bpf.Instruction {
+	return f(onMatchSkip, onFailSkip)
+}
+
+func equalNode(off uint32, size int, val uint32) node {
+	return nodeFunc(func(onMatchSkip, onFailSkip uint8) []bpf.Instruction {
+		return []bpf.Instruction{
+			bpf.LoadAbsolute{Off: off, Size: size},
+			bpf.JumpIf{Cond: bpf.JumpEqual, Val: val, SkipTrue: onMatchSkip, SkipFalse: onFailSkip},
+		}
+	})
+}
+
+func and(a, b node) node {
+	return nodeFunc(func(onMatchSkip, onFailSkip uint8) []bpf.Instruction {
+		bInstrs := b.emit(onMatchSkip, onFailSkip)
+		aInstrs := a.emit(0, onFailSkip+uint8(len(bInstrs)))
+		return append(aInstrs, bInstrs...)
+	})
+}
+
+func or(a, b node) node {
+	return nodeFunc(func(onMatchSkip, onFailSkip uint8) []bpf.Instruction {
+		bInstrs := b.emit(onMatchSkip, onFailSkip)
+		aInstrs := a.emit(onMatchSkip+uint8(len(bInstrs)), 0)
+		return append(aInstrs, bInstrs...)
+	})
+}
+
+// Filter is a composable BPF match expression over bare IPv4 packets.
+// Build one with UDP, TCP, SrcHost or DstHost, narrow it with DstPort/
+// SrcPort, and combine expressions with And/Or.
+type Filter struct {
+	n node
+}
+
+// UDP matches IPv4/UDP packets.
+func UDP() *Filter { return &Filter{n: equalNode(ipProtoOffset, 1, protoUDP)} }
+
+// TCP matches IPv4/TCP packets.
+func TCP() *Filter { return &Filter{n: equalNode(ipProtoOffset, 1, protoTCP)} }
+
+// SrcHost matches packets whose IPv4 source address is ip.
+func SrcHost(ip net.IP) *Filter { return &Filter{n: hostNode(ipSrcOffset, ip)} }
+
+// DstHost matches packets whose IPv4 destination address is ip.
+func DstHost(ip net.IP) *Filter { return &Filter{n: hostNode(ipDstOffset, ip)} }
+
+func hostNode(off uint32, ip net.IP) node {
+	v4 := ip.To4()
+	val := uint32(v4[0])<<24 | uint32(v4[1])<<16 | uint32(v4[2])<<8 | uint32(v4[3])
+	return equalNode(off, 4, val)
+}
+
+// DstPort narrows f to packets addressed to port. It assumes the IPv4
+// header carries no options, which holds for every packet DualConn
+// builds.
+func (f *Filter) DstPort(port uint16) *Filter {
+	return &Filter{n: and(f.n, equalNode(l4HeaderOff+2, 2, uint32(port)))}
+}
+
+// SrcPort narrows f to packets originating from port.
+func (f *Filter) SrcPort(port uint16) *Filter {
+	return &Filter{n: and(f.n, equalNode(l4HeaderOff, 2, uint32(port)))}
+}
+
+// And returns a filter matching packets that satisfy both f and other.
+func (f *Filter) And(other *Filter) *Filter {
+	return &Filter{n: and(f.n, other.n)}
+}
+
+// Or returns a filter matching packets that satisfy either f or other.
+func (f *Filter) Or(other *Filter) *Filter {
+	return &Filter{n: or(f.n, other.n)}
+}
+
+// Assemble compiles f into a raw BPF program suitable for
+// dualconn.DualConn.SetFilter.
+func (f *Filter) Assemble() ([]bpf.RawInstruction, error) {
+	instrs := f.n.emit(0, 1)
+	program := append(instrs, bpf.RetConstant{Val: 262144}, bpf.RetConstant{Val: 0})
+
+	raw, err := bpf.Assemble(program)
+	if err != nil {
+		return nil, fmt.Errorf("failed to assemble filter: %w", err)
+	}
+	return raw, nil
+}
@@ -0,0 +1,115 @@
+package filter
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+
+	"golang.org/x/net/bpf"
+)
+
+// buildIPv4UDP builds a bare IPv4/UDP packet (no link-layer header) with
+// the given protocol fields, matching what DualConn's raw AF_INET socket
+// sees.
+func buildIPv4UDP(srcIP, dstIP net.IP, srcPort, dstPort uint16) []byte {
+	pkt := make([]byte, 28)
+	pkt[0] = 0x45 // version 4, IHL 5
+	pkt[9] = 17   // protocol: UDP
+	copy(pkt[12:16], srcIP.To4())
+	copy(pkt[16:20], dstIP.To4())
+	binary.BigEndian.PutUint16(pkt[20:22], srcPort)
+	binary.BigEndian.PutUint16(pkt[22:24], dstPort)
+	return pkt
+}
+
+func buildIPv4TCP(srcIP, dstIP net.IP, srcPort, dstPort uint16) []byte {
+	pkt := make([]byte, 40)
+	pkt[0] = 0x45 // version 4, IHL 5
+	pkt[9] = 6    // protocol: TCP
+	copy(pkt[12:16], srcIP.To4())
+	copy(pkt[16:20], dstIP.To4())
+	binary.BigEndian.PutUint16(pkt[20:22], srcPort)
+	binary.BigEndian.PutUint16(pkt[22:24], dstPort)
+	return pkt
+}
+
+func mustAssemble(t *testing.T, f *Filter) []bpf.RawInstruction {
+	t.Helper()
+	raw, err := f.Assemble()
+	if err != nil {
+		t.Fatalf("Assemble: %v", err)
+	}
+	if len(raw) == 0 {
+		t.Fatal("expected a non-empty BPF program")
+	}
+	return raw
+}
+
+// runFilter reports whether the assembled filter accepts pkt, by running
+// it through a real BPF VM against crafted packet bytes instead of just
+// checking that assembly didn't error.
+func runFilter(t *testing.T, raw []bpf.RawInstruction, pkt []byte) bool {
+	t.Helper()
+	insts, _ := bpf.Disassemble(raw)
+	vm, err := bpf.NewVM(insts)
+	if err != nil {
+		t.Fatalf("NewVM: %v", err)
+	}
+	n, err := vm.Run(pkt)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	return n > 0
+}
+
+func TestUDPDstPortMatchesOnlyThatPort(t *testing.T) {
+	raw := mustAssemble(t, UDP().DstPort(53))
+
+	match := buildIPv4UDP(net.ParseIP("1.2.3.4"), net.ParseIP("5.6.7.8"), 9999, 53)
+	if !runFilter(t, raw, match) {
+		t.Error("expected filter to match a UDP packet to port 53")
+	}
+
+	noMatch := buildIPv4UDP(net.ParseIP("1.2.3.4"), net.ParseIP("5.6.7.8"), 9999, 80)
+	if runFilter(t, raw, noMatch) {
+		t.Error("expected filter not to match a UDP packet to port 80")
+	}
+
+	wrongProto := buildIPv4TCP(net.ParseIP("1.2.3.4"), net.ParseIP("5.6.7.8"), 9999, 53)
+	if runFilter(t, raw, wrongProto) {
+		t.Error("expected filter not to match a TCP packet even to the same port")
+	}
+}
+
+func TestAndRequiresBothSides(t *testing.T) {
+	raw := mustAssemble(t, UDP().DstPort(67).And(SrcHost(net.ParseIP("10.0.0.1"))))
+
+	match := buildIPv4UDP(net.ParseIP("10.0.0.1"), net.ParseIP("10.0.0.2"), 68, 67)
+	if !runFilter(t, raw, match) {
+		t.Error("expected filter to match UDP:67 from 10.0.0.1")
+	}
+
+	wrongHost := buildIPv4UDP(net.ParseIP("10.0.0.9"), net.ParseIP("10.0.0.2"), 68, 67)
+	if runFilter(t, raw, wrongHost) {
+		t.Error("expected filter not to match UDP:67 from a different host")
+	}
+}
+
+func TestOrMatchesEitherSide(t *testing.T) {
+	raw := mustAssemble(t, UDP().DstPort(67).And(SrcHost(net.ParseIP("10.0.0.1"))).Or(TCP().DstPort(80)))
+
+	dhcp := buildIPv4UDP(net.ParseIP("10.0.0.1"), net.ParseIP("10.0.0.2"), 68, 67)
+	if !runFilter(t, raw, dhcp) {
+		t.Error("expected filter to match the DHCP side of the Or")
+	}
+
+	http := buildIPv4TCP(net.ParseIP("1.2.3.4"), net.ParseIP("5.6.7.8"), 12345, 80)
+	if !runFilter(t, raw, http) {
+		t.Error("expected filter to match the TCP:80 side of the Or")
+	}
+
+	neither := buildIPv4UDP(net.ParseIP("9.9.9.9"), net.ParseIP("5.6.7.8"), 12345, 53)
+	if runFilter(t, raw, neither) {
+		t.Error("expected filter to reject a packet matching neither side")
+	}
+}
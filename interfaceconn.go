@@ -0,0 +1,195 @@
+package dualconn
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/mdlayher/packet"
+	"golang.org/x/net/bpf"
+)
+
+var broadcastMAC = net.HardwareAddr{0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+
+// InterfaceConnOption configures NewDualConnOnInterface.
+type InterfaceConnOption func(*interfaceConnConfig)
+
+type interfaceConnConfig struct {
+	clientPort uint16
+	nextHopMAC net.HardwareAddr
+}
+
+// WithClientPort sets the UDP destination port the installed BPF filter
+// accepts; packets to any other port are dropped in the kernel. It
+// defaults to 68, the DHCP client port.
+func WithClientPort(port uint16) InterfaceConnOption {
+	return func(cfg *interfaceConnConfig) {
+		cfg.clientPort = port
+	}
+}
+
+// WithNextHopMAC sets the destination MAC address used for outbound
+// frames. It defaults to the Ethernet broadcast address, appropriate for
+// DHCP DISCOVER/REQUEST sent before the client has a lease.
+func WithNextHopMAC(mac net.HardwareAddr) InterfaceConnOption {
+	return func(cfg *interfaceConnConfig) {
+		cfg.nextHopMAC = mac
+	}
+}
+
+// DualConnOnInterface sends and receives fully-formed Ethernet/IP/UDP
+// frames over a raw AF_PACKET socket bound to a single interface. Unlike
+// DualConn, it does not require the interface to have an IP address
+// configured, which makes it suitable for DHCP clients that must send
+// from 0.0.0.0 before they have a lease.
+type DualConnOnInterface struct {
+	conn *packet.Conn
+	ifi  *net.Interface
+
+	nextHopMAC net.HardwareAddr
+
+	timeout time.Duration
+
+	ttl uint8
+}
+
+// NewDualConnOnInterface opens an AF_PACKET socket bound to ifi for both
+// sending and receiving, and installs a BPF filter that only accepts
+// IPv4/UDP frames addressed to the configured client port (68 by
+// default). It is meant for protocols such as DHCP that must exchange
+// fully-formed packets before the interface has an IP address.
+func NewDualConnOnInterface(ifi *net.Interface, opts ...InterfaceConnOption) (*DualConnOnInterface, error) {
+	cfg := interfaceConnConfig{
+		clientPort: 68,
+		nextHopMAC: broadcastMAC,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	conn, err := packet.Listen(ifi, packet.Raw, int(ethPAll), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open AF_PACKET socket on %s: %w", ifi.Name, err)
+	}
+
+	filter, err := bpf.Assemble(udpDstPortBPF(cfg.clientPort))
+	if err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("failed to assemble BPF filter: %w", err)
+	}
+	if err := conn.SetBPF(filter); err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("failed to set BPF filter: %w", err)
+	}
+
+	return &DualConnOnInterface{
+		conn:       conn,
+		ifi:        ifi,
+		nextHopMAC: cfg.nextHopMAC,
+		ttl:        64,
+	}, nil
+}
+
+// ethPAll is ETH_P_ALL in host byte order, the protocol packet.Listen
+// expects to receive every Ethernet frame on the interface, since the BPF
+// filter below is what actually restricts what's delivered.
+const ethPAll = 0x0003
+
+// udpDstPortBPF builds a classic BPF program that accepts only IPv4/UDP
+// frames whose destination port equals port, rejecting everything else
+// in the kernel so unrelated traffic never reaches userspace.
+func udpDstPortBPF(port uint16) []bpf.Instruction {
+	const (
+		ethHeaderLen  = 14
+		ipProtoOffset = ethHeaderLen + 9
+	)
+	return []bpf.Instruction{
+		bpf.LoadAbsolute{Off: 12, Size: 2},
+		bpf.JumpIf{Cond: bpf.JumpEqual, Val: 0x0800, SkipFalse: 6},
+		bpf.LoadAbsolute{Off: ipProtoOffset, Size: 1},
+		bpf.JumpIf{Cond: bpf.JumpEqual, Val: 17, SkipFalse: 4},
+		bpf.LoadMemShift{Off: ethHeaderLen},
+		bpf.LoadIndirect{Off: ethHeaderLen + 2, Size: 2},
+		bpf.JumpIf{Cond: bpf.JumpEqual, Val: uint32(port), SkipFalse: 1},
+		bpf.RetConstant{Val: 262144},
+		bpf.RetConstant{Val: 0},
+	}
+}
+
+// SetTimeout sets the read/write timeout for the connection.
+func (c *DualConnOnInterface) SetTimeout(timeout time.Duration) {
+	c.timeout = timeout
+}
+
+// SetTTL sets the Time To Live (TTL) used for outgoing packets.
+func (c *DualConnOnInterface) SetTTL(ttl uint8) {
+	c.ttl = ttl
+}
+
+// WriteToIP sends payload as a UDP/IP/Ethernet frame from 0.0.0.0:localPort
+// to 255.255.255.255:remotePort, as DHCP clients do before they have an
+// IP address.
+func (c *DualConnOnInterface) WriteToIP(payload []byte, localPort, remotePort uint16) (int, error) {
+	ipPacket, err := EncodeIPPacket("0.0.0.0", "255.255.255.255", localPort, remotePort, payload, c.ttl, 0, 0)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode IP packet: %w", err)
+	}
+
+	frame, err := encodeEthernetFrame(c.ifi.HardwareAddr, c.nextHopMAC, ipPacket)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode Ethernet frame: %w", err)
+	}
+
+	if c.timeout > 0 {
+		_ = c.conn.SetWriteDeadline(time.Now().Add(c.timeout))
+	}
+	return c.conn.WriteTo(frame, &packet.Addr{HardwareAddr: c.nextHopMAC})
+}
+
+// ReadFrom reads a raw Ethernet frame off the interface and returns the
+// UDP payload it carries, stripping the Ethernet and IP/UDP headers the
+// BPF filter already matched.
+func (c *DualConnOnInterface) ReadFrom(b []byte) (int, error) {
+	if c.timeout > 0 {
+		_ = c.conn.SetReadDeadline(time.Now().Add(c.timeout))
+	}
+
+	frame := make([]byte, len(b)+64)
+	n, _, err := c.conn.ReadFrom(frame)
+	if err != nil {
+		return 0, err
+	}
+
+	payload, err := decodeUDPPayload(frame[:n])
+	if err != nil {
+		return 0, err
+	}
+	return copy(b, payload), nil
+}
+
+// DHCPTransaction sends send and waits up to timeout for a reply into
+// recv, resending send and waiting again on timeout up to retries times.
+// It is a thin wrapper around the send/wait/retry loop every DHCP client
+// implements.
+func (c *DualConnOnInterface) DHCPTransaction(send []byte, localPort, remotePort uint16, recv []byte, timeout time.Duration, retries int) (int, error) {
+	c.SetTimeout(timeout)
+
+	var lastErr error
+	for attempt := 0; attempt <= retries; attempt++ {
+		if _, err := c.WriteToIP(send, localPort, remotePort); err != nil {
+			return 0, fmt.Errorf("failed to send DHCP message: %w", err)
+		}
+
+		n, err := c.ReadFrom(recv)
+		if err == nil {
+			return n, nil
+		}
+		lastErr = err
+	}
+	return 0, fmt.Errorf("no DHCP reply after %d attempts: %w", retries+1, lastErr)
+}
+
+// Close closes the connection.
+func (c *DualConnOnInterface) Close() error {
+	return c.conn.Close()
+}
@@ -0,0 +1,71 @@
+package dualconn
+
+import (
+	"net"
+	"testing"
+
+	"golang.org/x/net/bpf"
+)
+
+func runBPF(t *testing.T, prog []bpf.Instruction, frame []byte) bool {
+	t.Helper()
+	vm, err := bpf.NewVM(prog)
+	if err != nil {
+		t.Fatalf("NewVM: %v", err)
+	}
+	n, err := vm.Run(frame)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	return n > 0
+}
+
+func TestUDPDstPortBPFMatchesOnlyConfiguredPort(t *testing.T) {
+	prog := udpDstPortBPF(68)
+
+	srcMAC := net.HardwareAddr{0x00, 0x11, 0x22, 0x33, 0x44, 0x55}
+	ipPacket, err := EncodeIPPacket("0.0.0.0", "255.255.255.255", 67, 68, []byte("dhcp"), 64, 0, 0)
+	if err != nil {
+		t.Fatalf("EncodeIPPacket: %v", err)
+	}
+	frame, err := encodeEthernetFrame(srcMAC, broadcastMAC, ipPacket)
+	if err != nil {
+		t.Fatalf("encodeEthernetFrame: %v", err)
+	}
+	if !runBPF(t, prog, frame) {
+		t.Error("expected filter to match a DHCP frame to port 68")
+	}
+
+	otherPacket, err := EncodeIPPacket("0.0.0.0", "255.255.255.255", 67, 53, []byte("dns"), 64, 0, 0)
+	if err != nil {
+		t.Fatalf("EncodeIPPacket: %v", err)
+	}
+	otherFrame, err := encodeEthernetFrame(srcMAC, broadcastMAC, otherPacket)
+	if err != nil {
+		t.Fatalf("encodeEthernetFrame: %v", err)
+	}
+	if runBPF(t, prog, otherFrame) {
+		t.Error("expected filter not to match a frame to a different port")
+	}
+}
+
+func TestEthernetFrameRoundTrip(t *testing.T) {
+	srcMAC := net.HardwareAddr{0x00, 0x11, 0x22, 0x33, 0x44, 0x55}
+
+	ipPacket, err := EncodeIPPacket("0.0.0.0", "255.255.255.255", 67, 68, []byte("payload"), 64, 0, 0)
+	if err != nil {
+		t.Fatalf("EncodeIPPacket: %v", err)
+	}
+	frame, err := encodeEthernetFrame(srcMAC, broadcastMAC, ipPacket)
+	if err != nil {
+		t.Fatalf("encodeEthernetFrame: %v", err)
+	}
+
+	payload, err := decodeUDPPayload(frame)
+	if err != nil {
+		t.Fatalf("decodeUDPPayload: %v", err)
+	}
+	if string(payload) != "payload" {
+		t.Errorf("payload = %q, want %q", payload, "payload")
+	}
+}